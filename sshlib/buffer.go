@@ -0,0 +1,220 @@
+package sshlib
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+appendData - Appends b to the buffer and wakes up anything blocked in waitForMatch.
+*/
+func (s *SSHLib) appendData(b []byte) {
+	s.logRecv(b)
+
+	s.bufMu.Lock()
+	s.buf.Write(b)
+	woken := s.changed
+	s.changed = make(chan struct{})
+	s.bufMu.Unlock()
+
+	close(woken)
+}
+
+/*
+reader - Reads data from stdout in a loop and appends it to the buffer until the
+		 underlying pipe is closed.
+*/
+func (s *SSHLib) reader() {
+	recvData := make([]byte, 1024)
+	for {
+		numBytes, err := s.Stdout.Read(recvData)
+
+		if numBytes > 0 {
+			s.appendData(recvData[:numBytes])
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+/*
+drain - Returns the entire current buffer and empties it. Used when a read times
+		out or its context is cancelled, matching the old "return whatever is in
+		the buffer" behaviour.
+*/
+func (s *SSHLib) drain() string {
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+
+	data := s.buf.String()
+	s.buf.Reset()
+	return data
+}
+
+/*
+waitForMatch - Blocks until match reports a hit against the buffer, ctx is done, or
+			   the buffer is woken. When overlap is >= 0, match is only ever handed
+			   buf[scanned-overlap:], not the whole buffer, so repeated calls don't
+			   rescan bytes they've already cleared; overlap must then be an upper
+			   bound on how far back a match can start (e.g. a match straddling two
+			   appends - half the prompt arriving in one read, half in the next -
+			   isn't missed as long as overlap covers the longest possible match).
+			   Passing overlap < 0 disables the slicing optimization and always
+			   scans from the start of the buffer; use this when no such bound
+			   exists, as is the case for general regexes (".*", "\s+", etc. can
+			   match far more than the pattern's own literal length). On a hit,
+			   match returns (returnEnd, trimStart) relative to the slice it was
+			   given: returnEnd marks the end of the data to hand back to the
+			   caller, trimStart marks where the buffer should be trimmed to for
+			   the next read (the two differ for ReadUntil, which - for backward
+			   compatibility - leaves the matched string itself in the buffer).
+*/
+func (s *SSHLib) waitForMatch(ctx context.Context, overlap int, match func(buf string) (returnEnd, trimStart int, ok bool)) (string, error) {
+	scanned := 0
+
+	for {
+		s.bufMu.Lock()
+		buf := s.buf.String()
+		woken := s.changed
+		s.bufMu.Unlock()
+
+		from := 0
+		if overlap >= 0 {
+			from = scanned - overlap
+			if from < 0 {
+				from = 0
+			}
+		}
+
+		if returnEnd, trimStart, ok := match(buf[from:]); ok {
+			return s.consume(from+returnEnd, from+trimStart), nil
+		}
+
+		scanned = len(buf)
+
+		select {
+		case <-woken:
+		case <-ctx.Done():
+			return s.drain(), ErrNoMatch
+		}
+	}
+}
+
+/*
+consume - Takes the current buffer's [0:returnEnd) as the data to return, then
+		  trims the buffer down to its [trimStart:) tail.
+*/
+func (s *SSHLib) consume(returnEnd, trimStart int) string {
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+
+	full := s.buf.String()
+	returnData := full[:returnEnd]
+
+	s.buf.Reset()
+	s.buf.WriteString(full[trimStart:])
+
+	return returnData
+}
+
+/*
+ReadUntilCtx - Reads until the string str is seen, then returns the output
+			   (including str). If ctx is done first, returns whatever is in the
+			   buffer and ErrNoMatch.
+*/
+func (s *SSHLib) ReadUntilCtx(ctx context.Context, str string) (string, error) {
+	return s.waitForMatch(ctx, len(str), func(buf string) (int, int, bool) {
+		idx := strings.Index(buf, str)
+		if idx == -1 {
+			return 0, 0, false
+		}
+		return idx + len(str), idx, true
+	})
+}
+
+/*
+ReadUntil - ReadUntilCtx with a plain timeout in seconds, kept for callers that
+			don't need explicit cancellation.
+*/
+func (s *SSHLib) ReadUntil(str string, timeout int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return s.ReadUntilCtx(ctx, str)
+}
+
+/*
+ReadUntilRegexCtx - Reads until regexStr matches, then returns everything up to
+					and including the match. If ctx is done first, returns
+					whatever is in the buffer and ErrNoMatch. Regexes have no
+					bounded max match length, so every call rescans the full
+					buffer rather than a trailing slice.
+*/
+func (s *SSHLib) ReadUntilRegexCtx(ctx context.Context, regexStr string) (string, error) {
+	cmpRe, err := regexp.Compile(regexStr)
+
+	if err != nil {
+		return "", err
+	}
+
+	return s.waitForMatch(ctx, -1, func(buf string) (int, int, bool) {
+		loc := cmpRe.FindStringIndex(buf)
+		if loc == nil {
+			return 0, 0, false
+		}
+		return loc[1], loc[1], true
+	})
+}
+
+/*
+ReadUntilRegex - ReadUntilRegexCtx with a plain timeout in seconds, kept for
+				 callers that don't need explicit cancellation.
+*/
+func (s *SSHLib) ReadUntilRegex(regexStr string, timeout int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return s.ReadUntilRegexCtx(ctx, regexStr)
+}
+
+/*
+ReadUntilRegexListCtx - Reads until one of regexList matches, then returns
+						everything up to and including that match. If ctx is done
+						first, returns whatever is in the buffer and ErrNoMatch.
+						Regexes have no bounded max match length, so every call
+						rescans the full buffer rather than a trailing slice.
+*/
+func (s *SSHLib) ReadUntilRegexListCtx(ctx context.Context, regexList []string) (string, error) {
+	cmpReList := make([]*regexp.Regexp, 0, len(regexList))
+
+	for _, reStr := range regexList {
+		cmpRe, err := regexp.Compile(reStr)
+
+		if err != nil {
+			return "", err
+		}
+
+		cmpReList = append(cmpReList, cmpRe)
+	}
+
+	return s.waitForMatch(ctx, -1, func(buf string) (int, int, bool) {
+		for _, cmpRe := range cmpReList {
+			if loc := cmpRe.FindStringIndex(buf); loc != nil {
+				return loc[1], loc[1], true
+			}
+		}
+		return 0, 0, false
+	})
+}
+
+/*
+ReadUntilRegexList - ReadUntilRegexListCtx with a plain timeout in seconds, kept
+					 for callers that don't need explicit cancellation.
+*/
+func (s *SSHLib) ReadUntilRegexList(regexList []string, timeout int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return s.ReadUntilRegexListCtx(ctx, regexList)
+}
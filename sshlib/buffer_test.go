@@ -0,0 +1,125 @@
+package sshlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSSHLib() *SSHLib {
+	return &SSHLib{changed: make(chan struct{})}
+}
+
+// appendLater appends b to s.buf shortly after the caller starts waiting, to
+// exercise the "match straddles two appends" path.
+func appendLater(s *SSHLib, b []byte) {
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.appendData(b)
+	}()
+}
+
+func TestReadUntilMatchSpansTwoAppends(t *testing.T) {
+	s := newTestSSHLib()
+	s.appendData([]byte("prefix-ab"))
+	appendLater(s, []byte("c-suffix"))
+
+	out, err := s.ReadUntil("abc", 2)
+	if err != nil {
+		t.Fatalf("ReadUntil: %v", err)
+	}
+	if out != "prefix-abc" {
+		t.Fatalf("got %q, want %q", out, "prefix-abc")
+	}
+
+	// ReadUntil keeps the matched string itself in the buffer, for backward
+	// compatibility - only the data before it is consumed.
+	if remaining := s.drain(); remaining != "abc-suffix" {
+		t.Fatalf("remaining buffer = %q, want %q", remaining, "abc-suffix")
+	}
+}
+
+func TestReadUntilOverlapBoundary(t *testing.T) {
+	s := newTestSSHLib()
+
+	// "match" is 5 bytes; feed it in two appends split in the middle so the
+	// second half alone, scanned without the overlap from the first call,
+	// would miss it.
+	s.appendData([]byte("leading-noise-mat"))
+	appendLater(s, []byte("ch-trailing"))
+
+	out, err := s.ReadUntil("match", 2)
+	if err != nil {
+		t.Fatalf("ReadUntil: %v", err)
+	}
+	if out != "leading-noise-match" {
+		t.Fatalf("got %q, want %q", out, "leading-noise-match")
+	}
+}
+
+func TestReadUntilRegexMatchLongerThanPattern(t *testing.T) {
+	s := newTestSSHLib()
+
+	// The pattern's literal length is far shorter than the text it actually
+	// matches here - a regression test for the bug where the scan window was
+	// bounded by len(regexStr) instead of the full buffer.
+	s.appendData([]byte("abc"))
+	appendLater(s, []byte(strings.Repeat("filler ", 20)+"xyz"))
+
+	out, err := s.ReadUntilRegex(`(?s)abc.*xyz`, 2)
+	if err != nil {
+		t.Fatalf("ReadUntilRegex: %v", err)
+	}
+	if !strings.HasPrefix(out, "abc") || !strings.HasSuffix(out, "xyz") {
+		t.Fatalf("got %q, want a match spanning abc...xyz", out)
+	}
+}
+
+func TestReadUntilRegexListFindsEitherPattern(t *testing.T) {
+	s := newTestSSHLib()
+	s.appendData([]byte("some output\n"))
+	appendLater(s, []byte("device# "))
+
+	out, err := s.ReadUntilRegexList([]string{`device#\s*$`, `unused-pattern`}, 2)
+	if err != nil {
+		t.Fatalf("ReadUntilRegexList: %v", err)
+	}
+	if out != "some output\ndevice# " {
+		t.Fatalf("got %q", out)
+	}
+}
+
+func TestReadUntilCtxTimeoutDrainsBuffer(t *testing.T) {
+	s := newTestSSHLib()
+	s.appendData([]byte("no match here"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	out, err := s.ReadUntilCtx(ctx, "never-seen")
+	if err != ErrNoMatch {
+		t.Fatalf("err = %v, want ErrNoMatch", err)
+	}
+	if out != "no match here" {
+		t.Fatalf("got %q, want the full buffer drained back", out)
+	}
+
+	if remaining := s.drain(); remaining != "" {
+		t.Fatalf("expected buffer to be empty after drain, got %q", remaining)
+	}
+}
+
+func TestConsumeTrimsToTrimStart(t *testing.T) {
+	s := newTestSSHLib()
+	s.appendData([]byte("0123456789"))
+
+	returned := s.consume(5, 7)
+	if returned != "01234" {
+		t.Fatalf("returned = %q, want %q", returned, "01234")
+	}
+
+	if remaining := s.drain(); remaining != "789" {
+		t.Fatalf("remaining = %q, want %q", remaining, "789")
+	}
+}
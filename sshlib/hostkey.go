@@ -0,0 +1,193 @@
+package sshlib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+/*
+hostKeyConfig - Host key verification settings, shared by SSHLib and ProxyHop. If
+				none of these are set, KnownHostsFile falls back to ~/.ssh/known_hosts
+				and unknown/mismatched keys are rejected.
+*/
+type hostKeyConfig struct {
+	KnownHostsFile        string
+	TOFU                  bool
+	PinnedFingerprint     string
+	InsecureIgnoreHostKey bool
+}
+
+/*
+WithKnownHostsFile - Sets the known_hosts file used to verify the remote host key.
+					 If never set, the default is ~/.ssh/known_hosts.
+*/
+func WithKnownHostsFile(path string) Option {
+	return func(s *SSHLib) error {
+		s.KnownHostsFile = path
+		return nil
+	}
+}
+
+/*
+WithTOFU - Enables trust-on-first-use: host keys not yet present in the known_hosts
+		   file are accepted and appended to it, while keys that mismatch an existing
+		   entry are still rejected.
+*/
+func WithTOFU() Option {
+	return func(s *SSHLib) error {
+		s.TOFU = true
+		return nil
+	}
+}
+
+/*
+WithPinnedFingerprint - Verifies the remote host key against a single pinned
+						 SHA256 fingerprint (the "SHA256:..." form printed by
+						 `ssh-keygen -lf`) instead of consulting known_hosts.
+*/
+func WithPinnedFingerprint(fingerprint string) Option {
+	return func(s *SSHLib) error {
+		s.PinnedFingerprint = fingerprint
+		return nil
+	}
+}
+
+/*
+WithInsecureIgnoreHostKey - Explicitly opts out of host key verification. Callers
+							have to reach for this by name; it is never the default.
+*/
+func WithInsecureIgnoreHostKey() Option {
+	return func(s *SSHLib) error {
+		s.InsecureIgnoreHostKey = true
+		return nil
+	}
+}
+
+/*
+hostKeyCallback - Builds the ssh.HostKeyCallback to use for this connection based on
+				   the configured verification strategy.
+*/
+func (s *hostKeyConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if s.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if s.PinnedFingerprint != "" {
+		return s.pinnedHostKeyCallback(), nil
+	}
+
+	path := s.KnownHostsFile
+	if path == "" {
+		path = defaultKnownHostsFile()
+	}
+
+	cb, err := knownhosts.New(path)
+
+	if err != nil {
+		if os.IsNotExist(err) && s.TOFU {
+			return tofuHostKeyCallback(path), nil
+		}
+		return nil, err
+	}
+
+	if s.TOFU {
+		return tofuFallbackCallback(cb, path), nil
+	}
+
+	return wrapKnownHostsCallback(cb), nil
+}
+
+/*
+pinnedHostKeyCallback - Accepts only a host key whose SHA256 fingerprint matches
+						 PinnedFingerprint.
+*/
+func (s *hostKeyConfig) pinnedHostKeyCallback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if fingerprint != s.PinnedFingerprint {
+			return fmt.Errorf("%w: fingerprint %s does not match pinned %s", ErrHostKeyMismatch, fingerprint, s.PinnedFingerprint)
+		}
+		return nil
+	}
+}
+
+/*
+wrapKnownHostsCallback - Wraps a knownhosts callback so its errors surface as
+						  ErrHostKeyMismatch.
+*/
+func wrapKnownHostsCallback(cb ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := cb(hostname, remote, key); err != nil {
+			return fmt.Errorf("%w: %v", ErrHostKeyMismatch, err)
+		}
+		return nil
+	}
+}
+
+/*
+tofuHostKeyCallback - Used when the known_hosts file doesn't exist yet: accepts any
+					   host key and records it for next time.
+*/
+func tofuHostKeyCallback(path string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return appendKnownHost(path, hostname, key)
+	}
+}
+
+/*
+tofuFallbackCallback - Used when the known_hosts file exists: defers to cb, but if
+						cb fails because the host simply has no entry yet (as opposed
+						to an entry that doesn't match), accepts the key and appends it.
+*/
+func tofuFallbackCallback(cb ssh.HostKeyCallback, path string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return appendKnownHost(path, hostname, key)
+		}
+
+		return fmt.Errorf("%w: %v", ErrHostKeyMismatch, err)
+	}
+}
+
+/*
+appendKnownHost - Appends a host key entry to the known_hosts file at path,
+				   creating it (and its entry) if necessary.
+*/
+func appendKnownHost(path string, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(knownhosts.Line([]string{hostname}, key) + "\n")
+	return err
+}
+
+/*
+defaultKnownHostsFile - Returns ~/.ssh/known_hosts for the current user, or "" if
+						 the home directory can't be determined.
+*/
+func defaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
@@ -0,0 +1,173 @@
+package sshlib
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// fakeAddr is a net.Addr standing in for a real remote address; the
+// knownhosts callback calls String() on it but the tests here only
+// exercise hostname-keyed entries.
+type fakeAddr struct{ addr string }
+
+func (f fakeAddr) Network() string { return "tcp" }
+func (f fakeAddr) String() string  { return f.addr }
+
+var testRemote net.Addr = fakeAddr{addr: "93.184.216.34:22"}
+
+func genHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey: %v", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("ssh.NewPublicKey: %v", err)
+	}
+
+	return sshPub
+}
+
+func writeKnownHosts(t *testing.T, hostname string, key ssh.PublicKey) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{hostname}, key) + "\n"
+
+	if err := os.WriteFile(path, []byte(line), 0600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	return path
+}
+
+func TestHostKeyCallbackKnownHostsMatch(t *testing.T) {
+	key := genHostKey(t)
+	path := writeKnownHosts(t, "example.com:22", key)
+
+	cfg := hostKeyConfig{KnownHostsFile: path}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	if err := cb("example.com:22", testRemote, key); err != nil {
+		t.Fatalf("expected matching host key to be accepted, got %v", err)
+	}
+}
+
+func TestHostKeyCallbackKnownHostsMismatch(t *testing.T) {
+	key := genHostKey(t)
+	other := genHostKey(t)
+	path := writeKnownHosts(t, "example.com:22", key)
+
+	cfg := hostKeyConfig{KnownHostsFile: path}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	err = cb("example.com:22", testRemote, other)
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("expected ErrHostKeyMismatch for a mismatched key, got %v", err)
+	}
+}
+
+func TestHostKeyCallbackTOFUMissingFile(t *testing.T) {
+	key := genHostKey(t)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+
+	cfg := hostKeyConfig{KnownHostsFile: path, TOFU: true}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	if err := cb("example.com:22", testRemote, key); err != nil {
+		t.Fatalf("expected TOFU to accept an unseen host when known_hosts doesn't exist, got %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected known_hosts to be created, os.ReadFile: %v", err)
+	}
+	if len(data) == 0 {
+		t.Fatal("expected the accepted host key to be appended to known_hosts")
+	}
+}
+
+func TestHostKeyCallbackTOFUNewHost(t *testing.T) {
+	existing := genHostKey(t)
+	path := writeKnownHosts(t, "other.example.com:22", existing)
+
+	newKey := genHostKey(t)
+	cfg := hostKeyConfig{KnownHostsFile: path, TOFU: true}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	if err := cb("example.com:22", testRemote, newKey); err != nil {
+		t.Fatalf("expected TOFU to accept a host with no existing entry, got %v", err)
+	}
+}
+
+func TestHostKeyCallbackTOFUStillRejectsMismatch(t *testing.T) {
+	key := genHostKey(t)
+	other := genHostKey(t)
+	path := writeKnownHosts(t, "example.com:22", key)
+
+	cfg := hostKeyConfig{KnownHostsFile: path, TOFU: true}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	err = cb("example.com:22", testRemote, other)
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("expected TOFU to still reject a key that mismatches an existing entry, got %v", err)
+	}
+}
+
+func TestHostKeyCallbackPinnedFingerprint(t *testing.T) {
+	key := genHostKey(t)
+	other := genHostKey(t)
+
+	cfg := hostKeyConfig{PinnedFingerprint: ssh.FingerprintSHA256(key)}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	if err := cb("example.com:22", testRemote, key); err != nil {
+		t.Fatalf("expected matching fingerprint to be accepted, got %v", err)
+	}
+
+	err = cb("example.com:22", testRemote, other)
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("expected ErrHostKeyMismatch for a non-matching fingerprint, got %v", err)
+	}
+}
+
+func TestHostKeyCallbackInsecureIgnore(t *testing.T) {
+	cfg := hostKeyConfig{InsecureIgnoreHostKey: true}
+	cb, err := cfg.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+
+	if err := cb("example.com:22", testRemote, genHostKey(t)); err != nil {
+		t.Fatalf("expected InsecureIgnoreHostKey to accept any key, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package sshlib
+
+import (
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+/*
+defaultKeepaliveInterval - Used when KeepaliveInterval is left at its zero value.
+*/
+const defaultKeepaliveInterval = 30 * time.Second
+
+/*
+defaultKeepaliveMaxMissed - Used when KeepaliveMaxMissed is left at its zero value.
+*/
+const defaultKeepaliveMaxMissed = 3
+
+/*
+keepaliveLoop - Periodically sends an OpenSSH-style keepalive request and counts
+				 consecutive failures to respond. After KeepaliveMaxMissed in a row
+				 it closes the connection and marks it dead, then stops; Open
+				 starts a fresh instance of this loop on every (re)connect.
+*/
+func (s *SSHLib) keepaliveLoop() {
+	defer close(s.keepaliveDone)
+
+	interval := s.KeepaliveInterval
+	if interval <= 0 {
+		interval = defaultKeepaliveInterval
+	}
+
+	maxMissed := s.KeepaliveMaxMissed
+	if maxMissed <= 0 {
+		maxMissed = defaultKeepaliveMaxMissed
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	stop := s.keepaliveStop
+	missed := 0
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// A SSH_MSG_REQUEST_FAILURE reply (ok == false, err == nil) still
+			// proves the transport is alive; only a transport-level error, or no
+			// reply within interval (e.g. a half-open TCP session), counts as a
+			// missed keepalive.
+			if s.sendKeepalive(s.getClient(), interval) {
+				missed = 0
+			} else {
+				missed++
+			}
+
+			if missed >= maxMissed {
+				s.markDead()
+				return
+			}
+		}
+	}
+}
+
+/*
+sendKeepalive - Sends the keepalive request over client and reports whether it
+				was answered within timeout. Takes client as a parameter rather
+				than reading s.Client directly so a concurrent Open/Reconnect
+				swapping s.Client can't race with the read; client.SendRequest
+				has no deadline of its own and blocks until a reply arrives, so
+				on a half-open TCP session it would hang forever - running it in
+				a goroutine and racing it against timeout lets a non-responding
+				session still count as a missed keepalive. The goroutine is
+				abandoned (not cancelled) on timeout and its result discarded
+				when it eventually returns.
+*/
+func (s *SSHLib) sendKeepalive(client *ssh.Client, timeout time.Duration) bool {
+	result := make(chan bool, 1)
+
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err == nil
+	}()
+
+	select {
+	case ok := <-result:
+		return ok
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+/*
+markDead - Marks the connection as dead and closes it, so that the next call
+		   relying on it (e.g. SSHAgent.SendCommand) can detect the loss and, if
+		   AutoReconnect is enabled, reconnect instead of hanging until its
+		   ReadUntil* timeout expires.
+*/
+func (s *SSHLib) markDead() {
+	atomic.StoreInt32(&s.dead, 1)
+	s.logEvent("keepalive missed, marking connection dead", "host", s.Host, "port", s.Port)
+	s.getClient().Close()
+}
+
+/*
+isDead - Reports whether the connection has been marked dead by keepaliveLoop.
+*/
+func (s *SSHLib) isDead() bool {
+	return atomic.LoadInt32(&s.dead) == 1
+}
@@ -0,0 +1,143 @@
+package sshlib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+/*
+Logger - A sink for a tcpdump-style transcript of an SSHLib connection: every byte
+		 written to the remote, every byte read back from it, and notable
+		 lifecycle events (connect, disconnect, auth failures, reconnects).
+*/
+type Logger interface {
+	Sent(b []byte)
+	Recv(b []byte)
+	Event(msg string, kv ...any)
+}
+
+/*
+SetLogger - Sets the logger used to capture this connection's transcript. Pass nil
+			to stop logging.
+*/
+func (s *SSHLib) SetLogger(l Logger) {
+	s.Logger = l
+}
+
+/*
+logSent - Forwards b to the configured Logger's Sent, if any.
+*/
+func (s *SSHLib) logSent(b []byte) {
+	if s.Logger != nil {
+		s.Logger.Sent(b)
+	}
+}
+
+/*
+logRecv - Forwards b to the configured Logger's Recv, if any.
+*/
+func (s *SSHLib) logRecv(b []byte) {
+	if s.Logger != nil {
+		s.Logger.Recv(b)
+	}
+}
+
+/*
+logEvent - Forwards msg/kv to the configured Logger's Event, if any.
+*/
+func (s *SSHLib) logEvent(msg string, kv ...any) {
+	if s.Logger != nil {
+		s.Logger.Event(msg, kv...)
+	}
+}
+
+/*
+SlogLogger - A Logger that writes to a *slog.Logger: traffic at debug level,
+			 events at info level.
+*/
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+/*
+NewSlogLogger - Creates a SlogLogger writing to logger.
+*/
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{Logger: logger}
+}
+
+/*
+Sent - Logs b as a debug-level "sent" record.
+*/
+func (l *SlogLogger) Sent(b []byte) {
+	l.Logger.Debug("sent", "bytes", len(b), "data", string(b))
+}
+
+/*
+Recv - Logs b as a debug-level "recv" record.
+*/
+func (l *SlogLogger) Recv(b []byte) {
+	l.Logger.Debug("recv", "bytes", len(b), "data", string(b))
+}
+
+/*
+Event - Logs msg/kv at info level.
+*/
+func (l *SlogLogger) Event(msg string, kv ...any) {
+	l.Logger.Info(msg, kv...)
+}
+
+/*
+WriterLogger - A Logger that writes a plain-text session transcript to an
+			   io.Writer, prefixing sent traffic with ">> ", received traffic with
+			   "<< ", and events with "-- ".
+*/
+type WriterLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+/*
+NewWriterLogger - Creates a WriterLogger writing to w.
+*/
+func NewWriterLogger(w io.Writer) *WriterLogger {
+	return &WriterLogger{w: w}
+}
+
+/*
+Sent - Writes b to the transcript prefixed with ">> ".
+*/
+func (l *WriterLogger) Sent(b []byte) {
+	l.writeLine(">> ", string(b))
+}
+
+/*
+Recv - Writes b to the transcript prefixed with "<< ".
+*/
+func (l *WriterLogger) Recv(b []byte) {
+	l.writeLine("<< ", string(b))
+}
+
+/*
+Event - Writes msg/kv to the transcript prefixed with "-- ".
+*/
+func (l *WriterLogger) Event(msg string, kv ...any) {
+	l.writeLine("-- ", fmt.Sprintf("%s %v", msg, kv))
+}
+
+/*
+writeLine - Writes prefix+body to w, adding a trailing newline if body doesn't
+			already end in one.
+*/
+func (l *WriterLogger) writeLine(prefix, body string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	io.WriteString(l.w, prefix)
+	io.WriteString(l.w, body)
+	if len(body) == 0 || body[len(body)-1] != '\n' {
+		io.WriteString(l.w, "\n")
+	}
+}
@@ -0,0 +1,90 @@
+package sshlib
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+/*
+Option - A functional option used to build up an SSHLib's AuthMethods chain at
+		 creation time. Options are applied in the order they are passed, and
+		 Open tries the resulting methods in that same order.
+*/
+type Option func(*SSHLib) error
+
+/*
+WithPassword - Adds password authentication to the auth method chain.
+*/
+func WithPassword(passwd string) Option {
+	return func(s *SSHLib) error {
+		s.Passwd = passwd
+		s.AuthMethods = append(s.AuthMethods, ssh.Password(passwd))
+		return nil
+	}
+}
+
+/*
+WithPrivateKey - Adds public key authentication using the private key file at keyPath.
+				 passphrase is only used if the key is encrypted, pass "" otherwise.
+*/
+func WithPrivateKey(keyPath, passphrase string) Option {
+	return func(s *SSHLib) error {
+		key, err := os.ReadFile(keyPath)
+
+		if err != nil {
+			return err
+		}
+
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.AuthMethods = append(s.AuthMethods, ssh.PublicKeys(signer))
+		return nil
+	}
+}
+
+/*
+WithAgent - Adds public key authentication backed by a running ssh-agent, dialing
+			SSH_AUTH_SOCK for the list of signers it offers.
+*/
+func WithAgent() Option {
+	return func(s *SSHLib) error {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+
+		if sock == "" {
+			return ErrNoAgent
+		}
+
+		conn, err := net.Dial("unix", sock)
+
+		if err != nil {
+			return ErrNoAgent
+		}
+
+		agentClient := agent.NewClient(conn)
+		s.AuthMethods = append(s.AuthMethods, ssh.PublicKeysCallback(agentClient.Signers))
+		return nil
+	}
+}
+
+/*
+WithKeyboardInteractive - Adds keyboard-interactive authentication (commonly used for
+						   MFA prompts) using the given challenge responder.
+*/
+func WithKeyboardInteractive(responder ssh.KeyboardInteractiveChallenge) Option {
+	return func(s *SSHLib) error {
+		s.AuthMethods = append(s.AuthMethods, ssh.KeyboardInteractive(responder))
+		return nil
+	}
+}
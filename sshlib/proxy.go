@@ -0,0 +1,146 @@
+package sshlib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+/*
+ProxyHop - A single bastion/jump host in an SSHLib.ProxyChain. The chain is dialed
+		   in order, each hop tunnelling the connection to the next over the
+		   previous hop's already-established ssh.Client.
+*/
+type ProxyHop struct {
+	Host        string
+	Port        string
+	User        string
+	AuthMethods []ssh.AuthMethod
+	hostKeyConfig
+}
+
+/*
+Addr - Returns the hop's "host:port" dial address.
+*/
+func (h *ProxyHop) Addr() string {
+	return h.Host + ":" + h.Port
+}
+
+/*
+clientConfig - Builds the ssh.ClientConfig used to authenticate to this hop.
+*/
+func (h *ProxyHop) clientConfig(timeout time.Duration) (*ssh.ClientConfig, error) {
+	hostKeyCallback, err := h.hostKeyCallback()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		Timeout:         timeout,
+		User:            h.User,
+		HostKeyCallback: hostKeyCallback,
+		Auth:            h.AuthMethods,
+	}, nil
+}
+
+/*
+dial - Establishes the *ssh.Client for this connection, tunnelling through
+	   ProxyChain first when one is configured.
+*/
+func (s *SSHLib) dial() (*ssh.Client, error) {
+	finalConfig, err := s.CreateSSHConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.ProxyChain) == 0 {
+		client, err := ssh.Dial("tcp", s.Host+":"+s.Port, finalConfig)
+
+		if err != nil {
+			return nil, classifyDialErr(err)
+		}
+		return client, nil
+	}
+
+	return s.dialThroughProxyChain(finalConfig)
+}
+
+/*
+dialThroughProxyChain - Dials the first hop directly, then tunnels through each
+						 subsequent hop's ssh.Client before finally tunnelling to
+						 s.Host/s.Port with finalConfig.
+*/
+func (s *SSHLib) dialThroughProxyChain(finalConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	hop := s.ProxyChain[0]
+	hopConfig, err := hop.clientConfig(s.timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.Dial("tcp", hop.Addr(), hopConfig)
+
+	if err != nil {
+		return nil, classifyDialErr(err)
+	}
+
+	for _, hop := range s.ProxyChain[1:] {
+		hopConfig, err := hop.clientConfig(s.timeout)
+
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+
+		client, err = dialNextHop(client, hop.Addr(), hopConfig)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dialNextHop(client, s.Host+":"+s.Port, finalConfig)
+}
+
+/*
+dialNextHop - Tunnels a new *ssh.Client to addr over an already-connected hop. On
+			  failure via is no longer useful to the caller, so it is closed here
+			  rather than leaked back up the chain.
+*/
+func dialNextHop(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := via.Dial("tcp", addr)
+
+	if err != nil {
+		via.Close()
+		return nil, ErrNoConnection
+	}
+
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+
+	if err != nil {
+		conn.Close()
+		via.Close()
+		return nil, classifyDialErr(err)
+	}
+
+	return ssh.NewClient(ncc, chans, reqs), nil
+}
+
+/*
+classifyDialErr - Maps a raw dial/handshake error to one of the library's
+				   sentinel errors.
+*/
+func classifyDialErr(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "host key verification failed"):
+		return fmt.Errorf("%w: %v", ErrHostKeyMismatch, err)
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		return fmt.Errorf("%w: %v", ErrAuthFailed, err)
+	default:
+		return ErrNoConnection
+	}
+}
@@ -1,7 +1,9 @@
 package sshlib
 
 import (
+	"context"
 	"strings"
+	"time"
 )
 
 /*
@@ -14,14 +16,30 @@ type SSHAgent struct {
 	Passwd      string
 	PromptRegex string
 	Timeout     int
-	Conn        SSHLib
+	Conn        *SSHLib
 	Connected   bool
+
+	// AutoReconnect - If true, a SendCommand* call that finds the connection
+	// marked dead by the keepalive loop transparently calls Reconnect before
+	// sending, instead of failing with ErrLostConnection.
+	AutoReconnect bool
 }
 
 /*
-Create - Creates an instance of the SSH agent and set the needed attributes.
+Create - Creates an instance of the SSH agent and set the needed attributes. host may be
+		  a bare hostname or a "user@host:port" shorthand, in which case the embedded user
+		  and/or port override the user and port arguments. Additional AuthMethods beyond
+		  passwd (e.g. WithPrivateKey, WithAgent, WithKeyboardInteractive) can be supplied
+		  via opts; Open tries them in the order given.
 */
-func Create(host, port, user, passwd, promptRegex string, timeout int) (*SSHAgent, error) {
+func Create(host, port, user, passwd, promptRegex string, timeout int, opts ...Option) (*SSHAgent, error) {
+	host, port, user = resolveTarget(host, port, user)
+
+	authOpts := opts
+	if passwd != "" {
+		authOpts = append([]Option{WithPassword(passwd)}, opts...)
+	}
+
 	a := &SSHAgent{}
 	a.Host = host
 	a.Port = port
@@ -29,8 +47,15 @@ func Create(host, port, user, passwd, promptRegex string, timeout int) (*SSHAgen
 	a.Passwd = passwd
 	a.PromptRegex = promptRegex
 	a.Timeout = timeout
-	a.Conn = CreateSSH(host, port, user, passwd, promptRegex, timeout)
-	err := a.Connect()
+
+	conn, err := CreateSSHWithOptions(host, port, user, promptRegex, timeout, authOpts...)
+
+	if err != nil {
+		return a, err
+	}
+
+	a.Conn = conn
+	err = a.Connect()
 
 	if err != nil {
 		return a, err
@@ -39,6 +64,24 @@ func Create(host, port, user, passwd, promptRegex string, timeout int) (*SSHAgen
 	return a, nil
 }
 
+/*
+resolveTarget - Splits a "user@host:port" shorthand out of host, falling back to the
+				given port/user when they are not present in host.
+*/
+func resolveTarget(host, port, user string) (string, string, string) {
+	if at := strings.Index(host, "@"); at != -1 {
+		user = host[:at]
+		host = host[at+1:]
+	}
+
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		port = host[idx+1:]
+		host = host[:idx]
+	}
+
+	return host, port, user
+}
+
 /*
 Connect - Attempts to connect to the given device.
 */
@@ -69,9 +112,21 @@ func (s *SSHAgent) Logout() {
 }
 
 /*
-SendCommandNoWait - Sends a command and does not wait for any return value.
+SendCommandNoWait - Sends a command and does not wait for any return value. If the
+					keepalive loop has marked the connection dead, this reconnects
+					first when AutoReconnect is set, or fails immediately otherwise.
 */
 func (s *SSHAgent) SendCommandNoWait(command string) error {
+	if s.Conn.isDead() {
+		if !s.AutoReconnect {
+			return ErrLostConnection
+		}
+
+		if err := s.Reconnect(); err != nil {
+			return ErrLostConnection
+		}
+	}
+
 	err := s.Connect()
 
 	if err != nil {
@@ -83,6 +138,38 @@ func (s *SSHAgent) SendCommandNoWait(command string) error {
 	return nil
 }
 
+/*
+SetKeepalive - Configures the keepalive interval and number of consecutive missed
+			   responses that mark the connection dead. Takes effect on the next
+			   (re)connect, since the keepalive loop reads these values when Open
+			   starts it; an agent returned by Create is already connected, so
+			   call Reconnect afterwards for the new values to apply.
+*/
+func (s *SSHAgent) SetKeepalive(interval time.Duration, maxMissed int) {
+	s.Conn.KeepaliveInterval = interval
+	s.Conn.KeepaliveMaxMissed = maxMissed
+}
+
+/*
+Reconnect - Closes the current connection, if any, and re-establishes it, replaying
+			the login sequence. Safe to call whether or not the agent believes
+			itself connected.
+*/
+func (s *SSHAgent) Reconnect() error {
+	s.Conn.logEvent("reconnecting", "host", s.Host, "port", s.Port)
+	s.Conn.Close()
+	s.SetConnected(false)
+	return s.Connect()
+}
+
+/*
+SetLogger - Sets the logger used to capture this agent's connection transcript.
+			See SetLogger on SSHLib.
+*/
+func (s *SSHAgent) SetLogger(l Logger) {
+	s.Conn.SetLogger(l)
+}
+
 /*
 SendCommand - Sends a command waits for promptRegex before returning.
 */
@@ -93,7 +180,31 @@ func (s *SSHAgent) SendCommand(command string) (string, error) {
 		return "", ErrLostConnection
 	}
 
-	output, _ := s.Conn.ReadUntilRegex(s.PromptRegex, s.Timeout)
+	output, err := s.Conn.ReadUntilRegex(s.PromptRegex, s.Timeout)
+
+	if err != nil {
+		return output, err
+	}
+
+	return output, nil
+}
+
+/*
+SendCommandCtx - Sends a command and waits for promptRegex before returning, giving
+				 up early if ctx is done instead of waiting out the full Timeout.
+*/
+func (s *SSHAgent) SendCommandCtx(ctx context.Context, command string) (string, error) {
+	err := s.SendCommandNoWait(command)
+
+	if err != nil {
+		return "", ErrLostConnection
+	}
+
+	output, err := s.Conn.ReadUntilRegexCtx(ctx, s.PromptRegex)
+
+	if err != nil {
+		return output, err
+	}
 
 	return output, nil
 }
@@ -136,6 +247,29 @@ func (s *SSHAgent) SendCommandWaitForList(command string, regexList []string) (s
 	return output, nil
 }
 
+/*
+SendCommandWaitForListCtx - Sends a command and waits for one of the regex strings
+							in the list, giving up early if ctx is done instead of
+							waiting out the full Timeout. The prompt regex is added
+							automatically.
+*/
+func (s *SSHAgent) SendCommandWaitForListCtx(ctx context.Context, command string, regexList []string) (string, error) {
+	err := s.SendCommandNoWait(command)
+
+	if err != nil {
+		return "", err
+	}
+
+	regexList = append(regexList, s.PromptRegex)
+	output, err := s.Conn.ReadUntilRegexListCtx(ctx, regexList)
+
+	if err != nil {
+		return output, err
+	}
+
+	return output, nil
+}
+
 /*
 SetConnected - Sets the ssh agent structs connected flag.
 */
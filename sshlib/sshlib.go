@@ -1,10 +1,11 @@
 package sshlib
 
 import (
+	"bytes"
 	"errors"
 	"io"
-	"regexp"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -15,6 +16,23 @@ ErrNoConnection - Error returned when the agent is unable to establish a connect
 */
 var ErrNoConnection = errors.New("unable to establish connection")
 
+/*
+ErrAuthFailed - Error returned when none of the configured auth methods were accepted.
+*/
+var ErrAuthFailed = errors.New("unable to authenticate with any configured auth method")
+
+/*
+ErrNoAgent - Error returned when WithAgent is used but SSH_AUTH_SOCK is not set or
+			 cannot be dialed.
+*/
+var ErrNoAgent = errors.New("unable to connect to ssh-agent")
+
+/*
+ErrHostKeyMismatch - Error returned when the remote host key cannot be verified against
+					 the configured known_hosts file or pinned fingerprint.
+*/
+var ErrHostKeyMismatch = errors.New("host key verification failed")
+
 /*
 ErrNoMatch - Error returned when no match is found in the returned data.
 */
@@ -39,52 +57,133 @@ var ErrInvalidAgent = errors.New("invalid agent type")
 SSHLib - A library for SSH.
 */
 type SSHLib struct {
-	Host      string
-	Port      string
-	Conn      *ssh.Session
-	Buffer    string
-	User      string
-	Passwd    string
-	PromptReg string
-	Stdin     io.WriteCloser
-	Stdout    io.Reader
-	Data      chan string
-	timeout   time.Duration
+	Host string
+	Port string
+
+	// Client is read by keepaliveLoop from a background goroutine for the
+	// lifetime of the connection and written by Open on every (re)connect, so
+	// all access - internal or external - must go through clientMu, getClient,
+	// and setClient rather than touching the field directly.
+	Client   *ssh.Client
+	clientMu sync.Mutex
+
+	Conn        *ssh.Session
+	User        string
+	Passwd      string
+	PromptReg   string
+	AuthMethods []ssh.AuthMethod
+
+	// Host key verification. If none of these are set, KnownHostsFile falls
+	// back to ~/.ssh/known_hosts and unknown/mismatched keys are rejected.
+	hostKeyConfig
+
+	// ProxyChain - Bastion hosts to tunnel the connection through, in order,
+	// before reaching Host/Port. See ProxyHop.
+	ProxyChain []ProxyHop
+
+	// Logger - Optional sink for a tcpdump-style transcript of this connection.
+	// See logger.go.
+	Logger Logger
+
+	Stdin   io.WriteCloser
+	Stdout  io.Reader
+	timeout time.Duration
+
+	// bufMu/buf/changed back the read path: reader() appends into buf and
+	// closes+replaces changed so any goroutine blocked in waitForMatch wakes
+	// up immediately instead of polling. See buffer.go.
+	bufMu   sync.Mutex
+	buf     bytes.Buffer
+	changed chan struct{}
+
+	// Keepalive. See keepalive.go and SSHAgent.SetKeepalive/Reconnect.
+	// keepaliveDone is closed by keepaliveLoop when it returns, so Close can
+	// wait for it to fully exit before Reconnect/Open swaps Client out from
+	// under it.
+	KeepaliveInterval  time.Duration
+	KeepaliveMaxMissed int
+	keepaliveStop      chan struct{}
+	keepaliveDone      chan struct{}
+	dead               int32
 }
 
 /*
-CreateSSH - Creates an instance of the SSHLib struct.
+getClient - Returns the current *ssh.Client, synchronized against concurrent
+			writes from Open/Reconnect.
 */
-func CreateSSH(host, port, user, passwd, promptReg string, timeout int) SSHLib {
-	s := SSHLib{}
+func (s *SSHLib) getClient() *ssh.Client {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	return s.Client
+}
+
+/*
+setClient - Replaces the current *ssh.Client, synchronized against concurrent
+			reads from keepaliveLoop.
+*/
+func (s *SSHLib) setClient(client *ssh.Client) {
+	s.clientMu.Lock()
+	s.Client = client
+	s.clientMu.Unlock()
+}
+
+/*
+CreateSSH - Creates an instance of the SSHLib struct configured for password auth.
+*/
+func CreateSSH(host, port, user, passwd, promptReg string, timeout int) *SSHLib {
+	s, _ := CreateSSHWithOptions(host, port, user, promptReg, timeout, WithPassword(passwd))
+	return s
+}
+
+/*
+CreateSSHWithOptions - Creates an instance of the SSHLib struct, applying the given
+						Options to build up its AuthMethods chain. Options are applied
+						in order and the first error returned by one of them aborts
+						construction.
+*/
+func CreateSSHWithOptions(host, port, user, promptReg string, timeout int, opts ...Option) (*SSHLib, error) {
+	s := &SSHLib{}
 	s.Host = host
 	s.Port = port
 	s.User = user
-	s.Passwd = passwd
 	s.PromptReg = promptReg
-	s.Data = make(chan string)
+	s.changed = make(chan struct{})
 	s.timeout = time.Duration(timeout) * time.Second
 
-	return s
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return s, err
+		}
+	}
+
+	return s, nil
 }
 
 /*
-Open - Opens an ssh connection.
+Open - Opens an ssh connection. Resets buf before starting the new session's
+		reader so a reconnect can't hand the new session leftover bytes from the
+		dying one.
 */
 func (s *SSHLib) Open() error {
-	sshConfig := s.CreateSSHConfig()
-	conn, err := ssh.Dial("tcp", s.Host+":"+s.Port, sshConfig)
+	conn, err := s.dial()
 
 	if err != nil {
-		return ErrNoConnection
+		s.logEvent("open failed", "host", s.Host, "port", s.Port, "error", err)
+		return err
 	}
 
+	s.setClient(conn)
 	s.Conn, err = conn.NewSession()
 
 	if err != nil {
+		s.logEvent("session failed", "host", s.Host, "port", s.Port, "error", err)
 		return ErrNoConnection
 	}
 
+	s.bufMu.Lock()
+	s.buf.Reset()
+	s.bufMu.Unlock()
+
 	s.Stdin, _ = s.Conn.StdinPipe()
 	s.Stdout, _ = s.Conn.StdoutPipe()
 	modes := ssh.TerminalModes{ssh.ECHO: 0}
@@ -95,173 +194,44 @@ func (s *SSHLib) Open() error {
 	_, err = s.ReadUntilRegex(s.PromptReg, 3)
 
 	if err != nil {
+		s.logEvent("prompt not found", "host", s.Host, "port", s.Port, "error", err)
 		return ErrNoPrompt
 	}
 
-	return nil
-}
-
-/*
-CreateSSHConfig - Creates the SSH configuration object.
-*/
-func (s *SSHLib) CreateSSHConfig() *ssh.ClientConfig {
-	config := &ssh.ClientConfig{
-		Timeout:         s.timeout,
-		User:            s.User,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Auth: []ssh.AuthMethod{
-			ssh.Password(s.Passwd),
-		},
-	}
-	return config
-}
-
-/*
-ReadUntil - ReadUntil - Reads until the string s is seen. Then returns the output.
-			If not match is found in <timeout> seconds, return whatever
-			is in the buffer.
-*/
-func (s *SSHLib) ReadUntil(str string, timeout int) (string, error) {
-	var returnData string
-
-	foundMatch := false
-	startTime := getTimestamp()
-	timeoutMs := int64(timeout) * 1000
-
-	for getTimestamp()-startTime < timeoutMs {
-		if strings.Contains(s.Buffer, str) {
-			var endRead = strings.Index(s.Buffer, str)
-			returnData = s.Buffer[:endRead+len(str)]
-			s.Buffer = s.Buffer[endRead:]
-			foundMatch = true
-			break
-		} else {
-			s.GetData()
-		}
-	}
-
-	if returnData == "" {
-		returnData = s.Buffer
-		s.Buffer = ""
-	}
-
-	if !foundMatch {
-		return returnData, ErrNoMatch
-	}
-	return returnData, nil
-}
-
-/*
-ReadUntilRegex - Read until the a regex match is found. Then return the output.
-				 If not match is found within <timeout> seconds, return whatever
-				 is in the buffer.
-*/
-func (s *SSHLib) ReadUntilRegex(regexStr string, timeout int) (string, error) {
-	var returnData string
-
-	foundMatch := false
-	startTime := getTimestamp()
-	timeoutMs := int64(timeout) * 1000
-	cmpRe, err := regexp.Compile(regexStr)
-
-	if err != nil {
-		return "", err
-	}
+	atomic.StoreInt32(&s.dead, 0)
+	s.keepaliveStop = make(chan struct{})
+	s.keepaliveDone = make(chan struct{})
+	go s.keepaliveLoop()
 
-	for getTimestamp()-startTime < timeoutMs {
-		if cmpRe.MatchString(s.Buffer) == true {
-			var endRead = cmpRe.FindStringIndex(s.Buffer)[1]
-			returnData = s.Buffer[:endRead]
-			s.Buffer = s.Buffer[endRead:]
-			foundMatch = true
-			break
-		} else {
-			s.GetData()
-		}
-	}
+	s.logEvent("connected", "host", s.Host, "port", s.Port)
 
-	if returnData == "" {
-		returnData = s.Buffer
-		s.Buffer = ""
-	}
-
-	if !foundMatch {
-		return returnData, ErrNoMatch
-	}
-	return returnData, nil
+	return nil
 }
 
 /*
-ReadUntilRegexList - Read until a match is found for one of the regex strings in the list.
-					 Then return the output. If not match is found within <timeout> seconds,
-					 return whatever is in the buffer.
+CreateSSHConfig - Creates the SSH configuration object. Returns an error if the
+				   configured host key verification strategy (known_hosts file,
+				   TOFU, pinned fingerprint) can't be set up.
 */
-func (s *SSHLib) ReadUntilRegexList(regexList []string, timeout int) (string, error) {
-	var returnData string
-	var cmpReList []*regexp.Regexp
-
-	foundMatch := false
-	startTime := getTimestamp()
-	timeoutMs := int64(timeout) * 1000
-
-	for i := 0; i < len(regexList); i++ {
-		var cmpRe, _ = regexp.Compile(regexList[i])
-		cmpReList = append(cmpReList, cmpRe)
+func (s *SSHLib) CreateSSHConfig() (*ssh.ClientConfig, error) {
+	authMethods := s.AuthMethods
+	if len(authMethods) == 0 && s.Passwd != "" {
+		authMethods = []ssh.AuthMethod{ssh.Password(s.Passwd)}
 	}
 
-	for !foundMatch && getTimestamp()-startTime < timeoutMs {
-		for i := 0; i < len(cmpReList); i++ {
-			if cmpReList[i].MatchString(s.Buffer) == true {
-				var endRead = cmpReList[i].FindStringIndex(s.Buffer)[1]
-				returnData = s.Buffer[:endRead]
-				s.Buffer = s.Buffer[endRead:]
-				foundMatch = true
-			}
-		}
-		if foundMatch == false {
-			s.GetData()
-		}
-	}
-
-	if returnData == "" {
-		returnData = s.Buffer
-		s.Buffer = ""
-	}
+	hostKeyCallback, err := s.hostKeyCallback()
 
-	if !foundMatch {
-		return returnData, ErrNoMatch
+	if err != nil {
+		return nil, err
 	}
-	return returnData, nil
-}
 
-/*
-GetData - Attempts to pull data from s.Data channel, if nothing is present sleep for 250ms.
-*/
-func (s *SSHLib) GetData() {
-	select {
-	case data := <-s.Data:
-		s.Buffer += data
-	default:
-		time.Sleep(250 * time.Millisecond)
-	}
-}
-
-/*
-reader - Reads data from stdout in a loop and adds it to s.Data channel.
-*/
-func (s *SSHLib) reader() {
-	recvData := make([]byte, 1024)
-	for {
-		numBytes, _ := s.Stdout.Read(recvData)
-		s.Data <- string(recvData[:numBytes])
+	config := &ssh.ClientConfig{
+		Timeout:         s.timeout,
+		User:            s.User,
+		HostKeyCallback: hostKeyCallback,
+		Auth:            authMethods,
 	}
-}
-
-/*
-getTimestamp - Gets the current number of milliseconds since epoch.
-*/
-func getTimestamp() int64 {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+	return config, nil
 }
 
 /*
@@ -269,6 +239,7 @@ Write - Writes the string plus a new line to the socket.
 */
 func (s *SSHLib) Write(str string) {
 	stringBytes := []byte(str + "\n")
+	s.logSent(stringBytes)
 	s.Stdin.Write(stringBytes)
 }
 
@@ -282,8 +253,26 @@ func (s *SSHLib) WriteThenReadUntil(sendStr string, matchStr string, timeout int
 }
 
 /*
-Close - Closes the telnet socket.
+Close - Closes the telnet socket. Waits for keepaliveLoop to fully exit before
+		returning, so a caller that immediately reconnects (e.g. SSHAgent.Reconnect)
+		can't have Open's setClient race with a keepalive tick still reading the
+		old Client.
 */
 func (s *SSHLib) Close() {
+	s.logEvent("closing", "host", s.Host, "port", s.Port)
+
+	if s.keepaliveStop != nil {
+		close(s.keepaliveStop)
+		s.keepaliveStop = nil
+	}
+
+	if s.keepaliveDone != nil {
+		<-s.keepaliveDone
+		s.keepaliveDone = nil
+	}
+
 	s.Conn.Close()
+	if client := s.getClient(); client != nil {
+		client.Close()
+	}
 }
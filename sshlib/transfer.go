@@ -0,0 +1,296 @@
+package sshlib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/pkg/sftp"
+)
+
+/*
+Upload - Copies the file at localPath to remotePath on the remote host. Uses SFTP
+		 when the remote offers the subsystem, falling back to the SCP sink
+		 protocol otherwise.
+*/
+func (s *SSHAgent) Upload(localPath, remotePath string) error {
+	f, err := os.Open(localPath)
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	if client, err := s.sftpClient(); err == nil {
+		defer client.Close()
+		return uploadSFTP(client, f, remotePath, info.Mode())
+	}
+
+	return s.scpUpload(f, remotePath, info.Mode(), info.Size())
+}
+
+/*
+Download - Copies remotePath on the remote host to the file at localPath. Uses
+		   SFTP when the remote offers the subsystem, falling back to the SCP
+		   source protocol otherwise.
+*/
+func (s *SSHAgent) Download(remotePath, localPath string) error {
+	f, err := os.Create(localPath)
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.DownloadWriter(remotePath, f)
+}
+
+/*
+UploadReader - Streams r to remotePath on the remote host with the given mode. If
+			   SFTP isn't available, the SCP fallback has to buffer r in memory to
+			   learn its size before it can speak the sink protocol.
+*/
+func (s *SSHAgent) UploadReader(r io.Reader, remotePath string, mode os.FileMode) error {
+	if client, err := s.sftpClient(); err == nil {
+		defer client.Close()
+		return uploadSFTP(client, r, remotePath, mode)
+	}
+
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	return s.scpUpload(bytes.NewReader(data), remotePath, mode, int64(len(data)))
+}
+
+/*
+DownloadWriter - Streams remotePath on the remote host into w.
+*/
+func (s *SSHAgent) DownloadWriter(remotePath string, w io.Writer) error {
+	if client, err := s.sftpClient(); err == nil {
+		defer client.Close()
+
+		remote, err := client.Open(remotePath)
+
+		if err != nil {
+			return err
+		}
+		defer remote.Close()
+
+		_, err = io.Copy(w, remote)
+		return err
+	}
+
+	return s.scpDownload(remotePath, w)
+}
+
+/*
+sftpClient - Opens an SFTP client over the agent's established *ssh.Client,
+			 returning an error (without attempting SCP) if the agent isn't connected.
+*/
+func (s *SSHAgent) sftpClient() (*sftp.Client, error) {
+	client := s.Conn.getClient()
+	if client == nil {
+		return nil, ErrNoConnection
+	}
+
+	return sftp.NewClient(client)
+}
+
+/*
+uploadSFTP - Creates remotePath over an SFTP client, copies r into it, and sets mode.
+*/
+func uploadSFTP(client *sftp.Client, r io.Reader, remotePath string, mode os.FileMode) error {
+	remote, err := client.Create(remotePath)
+
+	if err != nil {
+		return err
+	}
+	defer remote.Close()
+
+	if _, err := io.Copy(remote, r); err != nil {
+		return err
+	}
+
+	return client.Chmod(remotePath, mode)
+}
+
+/*
+scpUpload - Uploads r to remotePath by speaking the classic SCP sink protocol to a
+			`scp -t` process on the remote, for appliances with no SFTP subsystem.
+*/
+func (s *SSHAgent) scpUpload(r io.Reader, remotePath string, mode os.FileMode, size int64) error {
+	client := s.Conn.getClient()
+	if client == nil {
+		return ErrNoConnection
+	}
+
+	session, err := client.NewSession()
+
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+
+	if err != nil {
+		return err
+	}
+
+	stdout, err := session.StdoutPipe()
+
+	if err != nil {
+		return err
+	}
+
+	if err := session.Start(fmt.Sprintf("scp -t %s", remotePath)); err != nil {
+		return err
+	}
+
+	ackReader := bufio.NewReader(stdout)
+	_, name := path.Split(remotePath)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer stdin.Close()
+
+		if _, err := fmt.Fprintf(stdin, "C%04o %d %s\n", mode.Perm(), size, name); err != nil {
+			errCh <- err
+			return
+		}
+
+		if err := scpReadAck(ackReader); err != nil {
+			errCh <- err
+			return
+		}
+
+		if _, err := io.Copy(stdin, r); err != nil {
+			errCh <- err
+			return
+		}
+
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			errCh <- err
+			return
+		}
+
+		errCh <- scpReadAck(ackReader)
+	}()
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+/*
+scpDownload - Downloads remotePath into w by speaking the classic SCP source
+			  protocol to a `scp -f` process on the remote.
+*/
+func (s *SSHAgent) scpDownload(remotePath string, w io.Writer) error {
+	client := s.Conn.getClient()
+	if client == nil {
+		return ErrNoConnection
+	}
+
+	session, err := client.NewSession()
+
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+
+	if err != nil {
+		return err
+	}
+
+	stdout, err := session.StdoutPipe()
+
+	if err != nil {
+		return err
+	}
+
+	if err := session.Start(fmt.Sprintf("scp -f %s", remotePath)); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(stdout)
+	errCh := make(chan error, 1)
+
+	go func() {
+		var mode os.FileMode
+		var size int64
+		var name string
+
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			errCh <- err
+			return
+		}
+
+		if _, err := fmt.Fscanf(reader, "C%o %d %s\n", &mode, &size, &name); err != nil {
+			errCh <- err
+			return
+		}
+
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			errCh <- err
+			return
+		}
+
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			errCh <- err
+			return
+		}
+
+		if err := scpReadAck(reader); err != nil {
+			errCh <- err
+			return
+		}
+
+		_, err := stdin.Write([]byte{0})
+		errCh <- err
+	}()
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+/*
+scpReadAck - Reads a single SCP protocol status byte: 0 for OK, 1/2 for a warning or
+			 fatal error (followed by a message line), surfacing either as an error.
+*/
+func scpReadAck(r *bufio.Reader) error {
+	status, err := r.ReadByte()
+
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case 0:
+		return nil
+	case 1, 2:
+		msg, _ := r.ReadString('\n')
+		return fmt.Errorf("scp: %s", msg)
+	default:
+		return fmt.Errorf("scp: unexpected status byte %d", status)
+	}
+}
@@ -0,0 +1,158 @@
+package sshlib
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+/*
+tunnel - An io.Closer for a forwarded listener. Close stops accepting new
+		 connections and waits for every connection it has already spawned a
+		 proxying goroutine for to finish.
+*/
+type tunnel struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+/*
+Close - Stops the listener and waits for all inflight forwarded connections to
+		finish being proxied.
+*/
+func (t *tunnel) Close() error {
+	err := t.listener.Close()
+	t.wg.Wait()
+	return err
+}
+
+/*
+ForwardLocal - Binds localAddr and, for each accepted connection, dials remoteAddr
+			   through the SSH connection and proxies bytes between the two
+			   (OpenSSH's -L). Close the returned io.Closer to tear the tunnel down.
+*/
+func (s *SSHLib) ForwardLocal(localAddr, remoteAddr string) (io.Closer, error) {
+	client := s.getClient()
+	if client == nil {
+		return nil, ErrNoConnection
+	}
+
+	listener, err := net.Listen("tcp", localAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tunnel{listener: listener}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		for {
+			local, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			remote, err := client.Dial("tcp", remoteAddr)
+
+			if err != nil {
+				local.Close()
+				continue
+			}
+
+			t.wg.Add(1)
+			go func() {
+				defer t.wg.Done()
+				proxyConn(local, remote)
+			}()
+		}
+	}()
+
+	return t, nil
+}
+
+/*
+ForwardRemote - Asks the remote SSH server to listen on remoteAddr and, for each
+				connection it accepts, dials localAddr on this side and proxies
+				bytes between the two (OpenSSH's -R). Close the returned io.Closer
+				to tear the tunnel down.
+*/
+func (s *SSHLib) ForwardRemote(remoteAddr, localAddr string) (io.Closer, error) {
+	client := s.getClient()
+	if client == nil {
+		return nil, ErrNoConnection
+	}
+
+	listener, err := client.Listen("tcp", remoteAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tunnel{listener: listener}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		for {
+			remote, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			local, err := net.Dial("tcp", localAddr)
+
+			if err != nil {
+				remote.Close()
+				continue
+			}
+
+			t.wg.Add(1)
+			go func() {
+				defer t.wg.Done()
+				proxyConn(remote, local)
+			}()
+		}
+	}()
+
+	return t, nil
+}
+
+/*
+proxyConn - Copies bytes in both directions between a and b until one side closes,
+			then closes both ends and waits for the other direction's copy to finish.
+*/
+func proxyConn(a, b net.Conn) {
+	done := make(chan struct{})
+
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+		b.Close()
+		close(done)
+	}()
+
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+	<-done
+}
+
+/*
+ForwardLocal - See SSHLib.ForwardLocal.
+*/
+func (s *SSHAgent) ForwardLocal(localAddr, remoteAddr string) (io.Closer, error) {
+	return s.Conn.ForwardLocal(localAddr, remoteAddr)
+}
+
+/*
+ForwardRemote - See SSHLib.ForwardRemote.
+*/
+func (s *SSHAgent) ForwardRemote(remoteAddr, localAddr string) (io.Closer, error) {
+	return s.Conn.ForwardRemote(remoteAddr, localAddr)
+}